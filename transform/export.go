@@ -0,0 +1,132 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// exportFilter narrows which gifs go into an export bundle.
+type exportFilter struct {
+	// Checksums, when non-nil, restricts the export to these checksums.
+	Checksums map[string]bool
+	MinUses   int
+	NoNSFW    bool
+	WithVecs  bool
+}
+
+func (f exportFilter) matches(g *Gif) bool {
+	if f.Checksums != nil && !f.Checksums[g.Checksum] {
+		return false
+	}
+	if g.UseCount < f.MinUses {
+		return false
+	}
+	if f.NoNSFW && g.KNSFW {
+		return false
+	}
+	return true
+}
+
+// loadChecksumList reads a file of one checksum per line, as passed to
+// export's -checksum-list flag.
+func loadChecksumList(p string) (map[string]bool, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	set := map[string]bool{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line != "" {
+			set[line] = true
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// exportBundle streams every Gif in vecJSONLPath matching filt, plus its
+// bytes pulled from src, as a tar.gz to w: one gifs/<xx>/<checksum>.gif
+// entry per gif, followed by a manifest.jsonl entry holding the filtered
+// Gif records. Gif bytes are never buffered across entries — each is written
+// as it's read; only the manifest records are held in memory until the end.
+func exportBundle(vecJSONLPath string, filt exportFilter, src Store, w io.Writer) error {
+	f, err := os.Open(vecJSONLPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(w)
+	defer gz.Close()
+	tw := tar.NewWriter(gz)
+	defer tw.Close()
+
+	s := bufio.NewScanner(f)
+	buf := make([]byte, 0, 24*1024*1024)
+	s.Buffer(buf, 24*1024*1024)
+
+	ctx := context.Background()
+	manifest := &bytes.Buffer{}
+	exported := 0
+
+	for s.Scan() {
+		gif := Gif{}
+		if err := json.Unmarshal(s.Bytes(), &gif); err != nil {
+			return fmt.Errorf("failed to decode gif line: %w", err)
+		}
+		if !filt.matches(&gif) {
+			continue
+		}
+
+		bs, err := src.Get(ctx, gif.Checksum)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARN skipping '%s': failed to fetch bytes: %s\n", gif.Checksum, err)
+			continue
+		}
+
+		name := fmt.Sprintf("gifs/%s/%s.gif", shardPrefix(gif.Checksum), gif.Checksum)
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(bs))}); err != nil {
+			return fmt.Errorf("failed to write tar header for '%s': %w", gif.Checksum, err)
+		}
+		if _, err := tw.Write(bs); err != nil {
+			return fmt.Errorf("failed to write '%s' into bundle: %w", gif.Checksum, err)
+		}
+
+		if !filt.WithVecs {
+			gif.Vecs = nil
+		}
+		mbs, err := json.Marshal(gif)
+		if err != nil {
+			return fmt.Errorf("failed to serialize %s: %w", gif.Checksum, err)
+		}
+		fmt.Fprintf(manifest, "%s\n", strings.ReplaceAll(string(mbs), "\n", ""))
+		exported++
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("scanner failed: %w", err)
+	}
+
+	if err := tw.WriteHeader(&tar.Header{Name: "manifest.jsonl", Mode: 0644, Size: int64(manifest.Len())}); err != nil {
+		return fmt.Errorf("failed to write manifest.jsonl header: %w", err)
+	}
+	if _, err := tw.Write(manifest.Bytes()); err != nil {
+		return fmt.Errorf("failed to write manifest.jsonl: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d gifs\n", exported)
+	return nil
+}
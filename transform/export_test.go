@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func Test_exportFilter_matches(t *testing.T) {
+	cases := []struct {
+		name   string
+		filt   exportFilter
+		gif    Gif
+		expect bool
+	}{
+		{
+			name:   "no filter matches everything",
+			filt:   exportFilter{},
+			gif:    Gif{Checksum: "A"},
+			expect: true,
+		},
+		{
+			name:   "checksum list excludes unlisted checksums",
+			filt:   exportFilter{Checksums: map[string]bool{"A": true}},
+			gif:    Gif{Checksum: "B"},
+			expect: false,
+		},
+		{
+			name:   "checksum list includes listed checksums",
+			filt:   exportFilter{Checksums: map[string]bool{"A": true}},
+			gif:    Gif{Checksum: "A"},
+			expect: true,
+		},
+		{
+			name:   "min uses excludes gifs under the threshold",
+			filt:   exportFilter{MinUses: 5},
+			gif:    Gif{Checksum: "A", UseCount: 4},
+			expect: false,
+		},
+		{
+			name:   "min uses includes gifs at the threshold",
+			filt:   exportFilter{MinUses: 5},
+			gif:    Gif{Checksum: "A", UseCount: 5},
+			expect: true,
+		},
+		{
+			name:   "no nsfw excludes flagged gifs",
+			filt:   exportFilter{NoNSFW: true},
+			gif:    Gif{Checksum: "A", KNSFW: true},
+			expect: false,
+		},
+		{
+			name:   "no nsfw leaves unflagged gifs alone",
+			filt:   exportFilter{NoNSFW: true},
+			gif:    Gif{Checksum: "A", KNSFW: false},
+			expect: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result := c.filt.matches(&c.gif)
+			if result != c.expect {
+				t.Errorf("expected %v, got %v", c.expect, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fetcher knows how to go get the bytes for a single Use when we don't
+// already have them on disk. Several implementations can be tried in order
+// until one succeeds by wrapping them in a chainFetcher.
+type Fetcher interface {
+	Fetch(ctx context.Context, use Use) ([]byte, error)
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// chainFetcher tries each Fetcher in order, falling through to the next on
+// error. The "fetch" subcommand wires wayback-direct, wayback-closest, and
+// the live-host fallback together this way.
+type chainFetcher []Fetcher
+
+func (c chainFetcher) Fetch(ctx context.Context, use Use) ([]byte, error) {
+	var lastErr error
+	for _, f := range c {
+		bs, err := f.Fetch(ctx, use)
+		if err == nil {
+			return bs, nil
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("all fetchers failed, last error: %w", lastErr)
+}
+
+// checksumFor computes the same base32(SHA1(bytes)) digest the spark job
+// uses for Gif.Checksum, so a freshly fetched gif can be verified against
+// the manifest.
+func checksumFor(bs []byte) string {
+	sum := sha1.Sum(bs)
+	return strings.ToUpper(base32.StdEncoding.EncodeToString(sum[:]))
+}
+
+// toRawWaybackURL builds the "id_" wayback URL for a timestamp/url pair,
+// which serves the original bytes with no wayback toolbar/rewriting
+// injected. rawURL is used as-is; an existing replay URL is not unwrapped.
+func toRawWaybackURL(timestamp, rawURL string) string {
+	return fmt.Sprintf("https://web.archive.org/web/%sid_/%s", timestamp, rawURL)
+}
+
+// WaybackDirectFetcher fetches the exact snapshot a Use already points at.
+type WaybackDirectFetcher struct{}
+
+func (WaybackDirectFetcher) Fetch(ctx context.Context, use Use) ([]byte, error) {
+	return httpGet(ctx, toRawWaybackURL(use.Timestamp, use.URL))
+}
+
+// waybackAvailable is the relevant slice of archive.org's
+// /wayback/available response.
+type waybackAvailable struct {
+	ArchivedSnapshots struct {
+		Closest struct {
+			URL       string `json:"url"`
+			Timestamp string `json:"timestamp"`
+			Status    string `json:"status"`
+		} `json:"closest"`
+	} `json:"archived_snapshots"`
+}
+
+// WaybackClosestFetcher looks up the closest available snapshot to a Use's
+// timestamp via the availability API, then fetches that instead of
+// assuming the exact timestamp is still there.
+type WaybackClosestFetcher struct{}
+
+func (WaybackClosestFetcher) Fetch(ctx context.Context, use Use) ([]byte, error) {
+	lookupURL := fmt.Sprintf("https://archive.org/wayback/available?url=%s&timestamp=%s",
+		url.QueryEscape(use.URL), url.QueryEscape(use.Timestamp))
+	bs, err := httpGet(ctx, lookupURL)
+	if err != nil {
+		return nil, fmt.Errorf("availability lookup failed: %w", err)
+	}
+	var avail waybackAvailable
+	if err := json.Unmarshal(bs, &avail); err != nil {
+		return nil, fmt.Errorf("failed to decode availability response: %w", err)
+	}
+	closest := avail.ArchivedSnapshots.Closest
+	if closest.Timestamp == "" {
+		return nil, fmt.Errorf("no archived snapshot found for '%s'", use.URL)
+	}
+	return httpGet(ctx, toRawWaybackURL(closest.Timestamp, use.URL))
+}
+
+// LiveHostFetcher is the last resort: it fetches straight from the live
+// host, applying per-host rewrites for known hosting patterns whose direct
+// URLs have moved since the original crawl.
+type LiveHostFetcher struct {
+	// ImgurToken, when set, enables album expansion via the imgur API.
+	ImgurToken string
+}
+
+func (f LiveHostFetcher) Fetch(ctx context.Context, use Use) ([]byte, error) {
+	pu, err := url.Parse(use.URL)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse url '%s': %w", use.URL, err)
+	}
+
+	switch pu.Host {
+	case "imgur.com", "i.imgur.com", "www.imgur.com":
+		return f.fetchImgur(ctx, pu)
+	default:
+		return httpGet(ctx, use.URL)
+	}
+}
+
+// imgurAlbumPath matches /a/<id> and /gallery/<id> links, the two imgur path
+// shapes that name an album rather than a single image.
+var imgurAlbumPath = regexp.MustCompile(`^/(?:a|gallery)/([a-zA-Z0-9]+)`)
+
+// imgurAlbumResponse is the relevant slice of imgur's
+// GET /3/album/{id}/images response.
+type imgurAlbumResponse struct {
+	Data []struct {
+		Link string `json:"link"`
+	} `json:"data"`
+}
+
+func (f LiveHostFetcher) fetchImgur(ctx context.Context, pu *url.URL) ([]byte, error) {
+	if f.ImgurToken != "" {
+		if m := imgurAlbumPath.FindStringSubmatch(pu.Path); m != nil {
+			return f.fetchImgurAlbum(ctx, m[1])
+		}
+	}
+
+	direct := *pu
+	switch {
+	case strings.HasSuffix(direct.Path, ".gifv"):
+		direct.Path = strings.TrimSuffix(direct.Path, ".gifv") + ".mp4"
+		mp4, err := httpGet(ctx, direct.String())
+		if err != nil {
+			return nil, err
+		}
+		return mp4ToGif(ctx, mp4)
+	case strings.HasSuffix(direct.Path, ".mp4"):
+		mp4, err := httpGet(ctx, direct.String())
+		if err != nil {
+			return nil, err
+		}
+		return mp4ToGif(ctx, mp4)
+	default:
+		return httpGet(ctx, direct.String())
+	}
+}
+
+// fetchImgurAlbum looks up albumID via the imgur API and fetches the first
+// image in it, re-encoding a .mp4/.gifv result back to gif like fetchImgur
+// does for single images.
+func (f LiveHostFetcher) fetchImgurAlbum(ctx context.Context, albumID string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		fmt.Sprintf("https://api.imgur.com/3/album/%s/images", albumID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Client-ID "+f.ImgurToken)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("album lookup failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("album lookup for '%s': unexpected status %s", albumID, resp.Status)
+	}
+	bs, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var album imgurAlbumResponse
+	if err := json.Unmarshal(bs, &album); err != nil {
+		return nil, fmt.Errorf("failed to decode album response: %w", err)
+	}
+	if len(album.Data) == 0 {
+		return nil, fmt.Errorf("album '%s' has no images", albumID)
+	}
+
+	link := album.Data[0].Link
+	switch {
+	case strings.HasSuffix(link, ".gifv"), strings.HasSuffix(link, ".mp4"):
+		mp4URL := strings.TrimSuffix(strings.TrimSuffix(link, ".gifv"), ".mp4") + ".mp4"
+		mp4, err := httpGet(ctx, mp4URL)
+		if err != nil {
+			return nil, err
+		}
+		return mp4ToGif(ctx, mp4)
+	default:
+		return httpGet(ctx, link)
+	}
+}
+
+// mp4ToGif shells out to ffmpeg to re-encode imgur's .mp4 fallback back
+// into a gif, since that's what the rest of this pipeline expects.
+func mp4ToGif(ctx context.Context, mp4 []byte) ([]byte, error) {
+	tmpIn, err := os.CreateTemp("", "imgur-*.mp4")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpIn.Name())
+	if _, err := tmpIn.Write(mp4); err != nil {
+		tmpIn.Close()
+		return nil, err
+	}
+	tmpIn.Close()
+
+	tmpOut := tmpIn.Name() + ".gif"
+	defer os.Remove(tmpOut)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-y", "-i", tmpIn.Name(), tmpOut)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg re-encode failed: %w: %s", err, stderr.String())
+	}
+
+	return os.ReadFile(tmpOut)
+}
+
+func httpGet(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET '%s': unexpected status %s", u, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// hostThrottle serializes requests to the same host behind a minimum delay,
+// so fetchMissing's concurrent workers don't hammer any one site.
+type hostThrottle struct {
+	delay time.Duration
+	mu    sync.Mutex
+	last  map[string]time.Time
+}
+
+func newHostThrottle(delay time.Duration) *hostThrottle {
+	return &hostThrottle{delay: delay, last: map[string]time.Time{}}
+}
+
+func (h *hostThrottle) wait(host string) {
+	if h.delay <= 0 {
+		return
+	}
+	h.mu.Lock()
+	next := h.last[host].Add(h.delay)
+	if now := time.Now(); next.Before(now) {
+		next = now
+	}
+	h.last[host] = next
+	h.mu.Unlock()
+	if d := time.Until(next); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// writeSoLine marshals bs under hash as a soLine and appends it to outf,
+// serializing concurrent writers on mu.
+func writeSoLine(outf *os.File, mu *sync.Mutex, hash string, use Use, bs []byte) error {
+	sol := soLine{
+		Hash:   hash,
+		URL:    use.URL,
+		TS:     use.Timestamp,
+		Gifb64: base64.StdEncoding.EncodeToString(bs),
+	}
+	obs, err := json.Marshal(sol)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	fmt.Fprintf(outf, "%s\n", strings.ReplaceAll(string(obs), "\n", ""))
+	return nil
+}
+
+// fetchMissing reads gif entries out of jsonlPath (the file manifest
+// produces), skips any whose checksum already exists in present, fetches the
+// first use of the rest with fetcher, verifies the result against
+// Gif.Checksum, and appends a soLine per success to outPath in the same
+// format missing produces. On a checksum mismatch, it appends a second
+// soLine under the actually-computed hash too, so both the expected and
+// the real association survive for manual reconciliation. present may be
+// nil, in which case nothing is skipped.
+func fetchMissing(jsonlPath, outPath string, present Store, fetcher Fetcher, parallel int, perHostDelay time.Duration) error {
+	f, err := os.Open(jsonlPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	outf, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer outf.Close()
+
+	s := bufio.NewScanner(f)
+	buf := make([]byte, 0, 24*1024*1024)
+	s.Buffer(buf, 24*1024*1024)
+
+	jobs := make(chan Gif, parallel*2)
+	var writeMu sync.Mutex
+	var wg sync.WaitGroup
+	throttle := newHostThrottle(perHostDelay)
+
+	fetched, mismatched, failed, skipped := 0, 0, 0, 0
+	var statsMu sync.Mutex
+
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := context.Background()
+			for gif := range jobs {
+				if len(gif.Uses) == 0 {
+					continue
+				}
+				use := gif.Uses[0]
+				if pu, err := url.Parse(use.URL); err == nil {
+					throttle.wait(pu.Host)
+				}
+
+				bs, err := fetcher.Fetch(ctx, use)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "WARN failed to fetch '%s' (%s): %s\n", gif.Checksum, use.URL, err)
+					statsMu.Lock()
+					failed++
+					statsMu.Unlock()
+					continue
+				}
+
+				if got := checksumFor(bs); got != gif.Checksum {
+					fmt.Fprintf(os.Stderr, "WARN checksum mismatch for '%s': got '%s'; keeping both for manual reconciliation\n", gif.Checksum, got)
+					statsMu.Lock()
+					mismatched++
+					statsMu.Unlock()
+					if err := writeSoLine(outf, &writeMu, got, use, bs); err != nil {
+						fmt.Fprintf(os.Stderr, "WARN failed to serialize mismatch record for '%s': %s\n", got, err)
+					}
+				}
+
+				if err := writeSoLine(outf, &writeMu, gif.Checksum, use, bs); err != nil {
+					fmt.Fprintf(os.Stderr, "WARN failed to serialize '%s': %s\n", gif.Checksum, err)
+					continue
+				}
+
+				statsMu.Lock()
+				fetched++
+				statsMu.Unlock()
+			}
+		}()
+	}
+
+	ctx := context.Background()
+	for s.Scan() {
+		var gif Gif
+		if err := json.Unmarshal(s.Bytes(), &gif); err != nil {
+			close(jobs)
+			wg.Wait()
+			return fmt.Errorf("could not deserialize '%s': %w", s.Text(), err)
+		}
+		if present != nil {
+			have, err := present.Stat(ctx, gif.Checksum)
+			if err != nil {
+				close(jobs)
+				wg.Wait()
+				return fmt.Errorf("failed to check '%s' against the existing store: %w", gif.Checksum, err)
+			}
+			if have {
+				skipped++
+				continue
+			}
+		}
+		jobs <- gif
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := s.Err(); err != nil {
+		return err
+	}
+
+	fmt.Printf("fetched %d | mismatched %d | failed %d | already present %d\n", fetched, mismatched, failed, skipped)
+	return nil
+}
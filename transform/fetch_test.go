@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func Test_checksumFor(t *testing.T) {
+	got := checksumFor([]byte("GIF89a"))
+	want := "EXE3G6XDNIFAQMMNJXFHZJL6VGGXO2BB"
+	if got != want {
+		t.Errorf("expected '%s', got '%s'", want, got)
+	}
+}
+
+type stubFetcher struct {
+	bs  []byte
+	err error
+}
+
+func (s stubFetcher) Fetch(ctx context.Context, use Use) ([]byte, error) {
+	return s.bs, s.err
+}
+
+func Test_chainFetcher_Fetch(t *testing.T) {
+	t.Run("first fetcher succeeds", func(t *testing.T) {
+		c := chainFetcher{stubFetcher{bs: []byte("ok")}, stubFetcher{err: errors.New("should not be called")}}
+		bs, err := c.Fetch(context.Background(), Use{})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if string(bs) != "ok" {
+			t.Errorf("expected 'ok', got '%s'", bs)
+		}
+	})
+
+	t.Run("falls through to the next fetcher on error", func(t *testing.T) {
+		c := chainFetcher{stubFetcher{err: errors.New("first failed")}, stubFetcher{bs: []byte("fallback")}}
+		bs, err := c.Fetch(context.Background(), Use{})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if string(bs) != "fallback" {
+			t.Errorf("expected 'fallback', got '%s'", bs)
+		}
+	})
+
+	t.Run("all fetchers failing returns the last error", func(t *testing.T) {
+		secondErr := errors.New("second failed")
+		c := chainFetcher{stubFetcher{err: errors.New("first failed")}, stubFetcher{err: secondErr}}
+		_, err := c.Fetch(context.Background(), Use{})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		if !errors.Is(err, secondErr) {
+			t.Errorf("expected the last fetcher's error to be wrapped, got '%s'", err)
+		}
+	})
+}
+
+func Test_writeSoLine(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "out.jsonl")
+	outf, err := os.Create(p)
+	if err != nil {
+		t.Fatalf("failed to create fixture: %s", err)
+	}
+	defer outf.Close()
+
+	var mu sync.Mutex
+	use := Use{URL: "http://example.com/cool.gif", Timestamp: "20031224055733"}
+	if err := writeSoLine(outf, &mu, "AAAA", use, []byte("gifbytes")); err != nil {
+		t.Fatalf("writeSoLine failed: %s", err)
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		t.Fatalf("failed to reopen fixture: %s", err)
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	if !s.Scan() {
+		t.Fatal("expected a line to be written")
+	}
+	var sol soLine
+	if err := json.Unmarshal(s.Bytes(), &sol); err != nil {
+		t.Fatalf("failed to decode written line: %s", err)
+	}
+	if sol.Hash != "AAAA" || sol.URL != use.URL || sol.TS != use.Timestamp {
+		t.Errorf("expected hash/url/ts to round-trip, got %+v", sol)
+	}
+	if s.Scan() {
+		t.Error("expected exactly one line to be written")
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// altMergedPath is where mergeAlt writes gifcities.jsonl back out once
+// img alt text has been folded into Gif.Uses[i].Alt.
+const altMergedPath = "./data/gifcities_alt.jsonl"
+
+// findImgsHTML tokenizes s with golang.org/x/net/html and returns every
+// <img> tag referencing a gif, however it's quoted, cased, or entity
+// encoded. It replaces the old findImgs/extractProp byte-scanner, which
+// broke on unquoted attributes and anything but simple double-quoted
+// values. A tag truncated before its closing '>' (e.g. a page cut off
+// mid-crawl) yields no token at all and is silently dropped, the same as
+// any other unparseable trailing fragment.
+func findImgsHTML(s string) []img {
+	out := []img{}
+	z := html.NewTokenizer(strings.NewReader(s))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			// io.EOF or a malformed document either way — nothing more to read.
+			return out
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := z.Token()
+			if token.DataAtom != atom.Img {
+				continue
+			}
+			im := img{}
+			for _, a := range token.Attr {
+				switch strings.ToLower(a.Key) {
+				case "src":
+					im.Src = a.Val
+				case "alt":
+					im.Alt = a.Val
+				case "title":
+					im.Title = a.Val
+				case "longdesc":
+					im.Longdesc = a.Val
+				}
+			}
+			if !strings.Contains(strings.ToLower(im.Src), ".gif") {
+				continue
+			}
+			if im.Src == "" && im.Alt == "" {
+				continue
+			}
+			out = append(out, im)
+		}
+	}
+}
+
+// resolveSrc resolves an <img src> attribute (which may be relative)
+// against the page it was found on, so it can be matched against the
+// absolute gif URL recorded in a manifest Use.
+func resolveSrc(pageURL, src string) (string, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", fmt.Errorf("could not parse page url '%s': %w", pageURL, err)
+	}
+	ref, err := url.Parse(src)
+	if err != nil {
+		return "", fmt.Errorf("could not parse src '%s': %w", src, err)
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// mergeAlt reads the jsonl that alt produces and folds each img's alt text
+// into the matching Gif.Uses[i].Alt in gifsPath, keyed by (page URL,
+// resolved src), then writes the merged gifs out to outPath.
+func mergeAlt(gifsPath, altPath, outPath string) error {
+	gifs, err := loadGifsByHash(gifsPath)
+	if err != nil {
+		return fmt.Errorf("failed to load '%s': %w", gifsPath, err)
+	}
+
+	byPageAndSrc := map[string]*Use{}
+	for _, gif := range gifs {
+		for i := range gif.Uses {
+			use := &gif.Uses[i]
+			if use.Page == nil {
+				continue
+			}
+			byPageAndSrc[use.Page.URL+"|"+use.URL] = use
+		}
+	}
+
+	af, err := os.Open(altPath)
+	if err != nil {
+		return err
+	}
+	defer af.Close()
+
+	s := bufio.NewScanner(af)
+	buf := make([]byte, 0, 24*1024*1024)
+	s.Buffer(buf, 24*1024*1024)
+
+	matched := 0
+	for s.Scan() {
+		p := htmlpayload{}
+		if err := json.Unmarshal(s.Bytes(), &p); err != nil {
+			return fmt.Errorf("failed to decode alt line: %w", err)
+		}
+		for _, im := range p.Imgs {
+			if im.Alt == "" {
+				continue
+			}
+			resolved, err := resolveSrc(p.URL, im.Src)
+			if err != nil {
+				continue
+			}
+			if use, ok := byPageAndSrc[p.URL+"|"+resolved]; ok {
+				use.Alt = im.Alt
+				matched++
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("alt scanner failed: %w", err)
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	for _, gif := range gifs {
+		bs, err := json.Marshal(gif)
+		if err != nil {
+			return fmt.Errorf("failed to serialize %s: %w", gif.Checksum, err)
+		}
+		fmt.Fprintf(out, "%s\n", strings.ReplaceAll(string(bs), "\n", ""))
+	}
+
+	fmt.Printf("matched %d alt texts into '%s'\n", matched, outPath)
+	return nil
+}
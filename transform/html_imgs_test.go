@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_findImgsHTML(t *testing.T) {
+	cases := []struct {
+		name   string
+		html   string
+		expect []img
+	}{
+		{
+			name:   "simple double-quoted attrs",
+			html:   `<img src="cool.gif" alt="a cool gif">`,
+			expect: []img{{Src: "cool.gif", Alt: "a cool gif"}},
+		},
+		{
+			name:   "single-quoted attrs",
+			html:   `<img src='cool.gif' alt='a cool gif'>`,
+			expect: []img{{Src: "cool.gif", Alt: "a cool gif"}},
+		},
+		{
+			name:   "unquoted attrs",
+			html:   `<img src=cool.gif alt=wow>`,
+			expect: []img{{Src: "cool.gif", Alt: "wow"}},
+		},
+		{
+			name:   "tag truncated before its closing '>' yields no token, not a partial match",
+			html:   `<img src="cool.gif" alt="oops`,
+			expect: []img{},
+		},
+		{
+			name:   "entity-encoded alt text is decoded",
+			html:   `<img src="cool.gif" alt="fish &amp; chips">`,
+			expect: []img{{Src: "cool.gif", Alt: "fish & chips"}},
+		},
+		{
+			name:   "case-insensitive tag and attr names",
+			html:   `<IMG SRC="cool.gif" ALT="shouting">`,
+			expect: []img{{Src: "cool.gif", Alt: "shouting"}},
+		},
+		{
+			name:   "img inside script block is not a real tag",
+			html:   `<script>var x = "<img src=\"cool.gif\" alt=\"fake\">";</script>`,
+			expect: []img{},
+		},
+		{
+			name:   "img inside style block is not a real tag",
+			html:   `<style>/* <img src="cool.gif" alt="fake"> */</style>`,
+			expect: []img{},
+		},
+		{
+			name:   "non-gif src is skipped",
+			html:   `<img src="cool.png" alt="not a gif">`,
+			expect: []img{},
+		},
+		{
+			name:   "self-closing xhtml style tag",
+			html:   `<img src="cool.gif" alt="neat" />`,
+			expect: []img{{Src: "cool.gif", Alt: "neat"}},
+		},
+		{
+			name:   "title and longdesc are captured too",
+			html:   `<img src="cool.gif" alt="neat" title="a title" longdesc="desc.html">`,
+			expect: []img{{Src: "cool.gif", Alt: "neat", Title: "a title", Longdesc: "desc.html"}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result := findImgsHTML(c.html)
+			if len(result) != len(c.expect) {
+				t.Fatalf("expected %d imgs, got %d: %v", len(c.expect), len(result), result)
+			}
+			for i, im := range result {
+				if im != c.expect[i] {
+					t.Errorf("expected %+v, got %+v", c.expect[i], im)
+				}
+			}
+		})
+	}
+}
+
+func Test_mergeAlt(t *testing.T) {
+	dir := t.TempDir()
+	gifsPath := filepath.Join(dir, "gifcities.jsonl")
+	altPath := filepath.Join(dir, "alt.jsonl")
+	outPath := filepath.Join(dir, "out.jsonl")
+
+	gif := Gif{
+		Checksum: "MA2RY6GRLVEBI5AJ5EUGLQUEECB3GS3V",
+		Uses: []Use{
+			{URL: "http://example.com/cool.gif", Page: &Page{URL: "http://example.com/page.html"}},
+		},
+	}
+	writeJSONLine(t, gifsPath, gif)
+
+	payload := htmlpayload{
+		URL:  "http://example.com/page.html",
+		Imgs: []img{{Src: "cool.gif", Alt: "a cool gif"}},
+	}
+	writeJSONLine(t, altPath, payload)
+
+	if err := mergeAlt(gifsPath, altPath, outPath); err != nil {
+		t.Fatalf("mergeAlt failed: %s", err)
+	}
+
+	merged := readGif(t, outPath)
+	if merged.Uses[0].Alt != "a cool gif" {
+		t.Errorf("expected alt text to be merged in, got '%s'", merged.Uses[0].Alt)
+	}
+}
+
+func Test_mergeAlt_noMatchLeavesAltEmpty(t *testing.T) {
+	dir := t.TempDir()
+	gifsPath := filepath.Join(dir, "gifcities.jsonl")
+	altPath := filepath.Join(dir, "alt.jsonl")
+	outPath := filepath.Join(dir, "out.jsonl")
+
+	gif := Gif{
+		Checksum: "MA2RY6GRLVEBI5AJ5EUGLQUEECB3GS3V",
+		Uses: []Use{
+			{URL: "http://example.com/cool.gif", Page: &Page{URL: "http://example.com/page.html"}},
+		},
+	}
+	writeJSONLine(t, gifsPath, gif)
+
+	payload := htmlpayload{
+		URL:  "http://example.com/other-page.html",
+		Imgs: []img{{Src: "cool.gif", Alt: "wrong page"}},
+	}
+	writeJSONLine(t, altPath, payload)
+
+	if err := mergeAlt(gifsPath, altPath, outPath); err != nil {
+		t.Fatalf("mergeAlt failed: %s", err)
+	}
+
+	merged := readGif(t, outPath)
+	if merged.Uses[0].Alt != "" {
+		t.Errorf("expected no alt text to be merged for a mismatched page, got '%s'", merged.Uses[0].Alt)
+	}
+}
+
+func writeJSONLine(t *testing.T, p string, v interface{}) {
+	t.Helper()
+	bs, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("failed to marshal fixture: %s", err)
+	}
+	if err := os.WriteFile(p, append(bs, '\n'), 0644); err != nil {
+		t.Fatalf("failed to write fixture '%s': %s", p, err)
+	}
+}
+
+func readGif(t *testing.T, p string) Gif {
+	t.Helper()
+	bs, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatalf("failed to read '%s': %s", p, err)
+	}
+	var gif Gif
+	if err := json.Unmarshal(bs, &gif); err != nil {
+		t.Fatalf("failed to decode '%s': %s", p, err)
+	}
+	return gif
+}
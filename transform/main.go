@@ -8,16 +8,22 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"math/rand"
 	"net/url"
 	"os"
+	"os/signal"
 	"path"
-	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/cheggaaa/pb/v3"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 )
@@ -103,7 +109,7 @@ func parseUse(fields []string) (Use, error) {
 		Filename:  filename,
 		Path:      strings.TrimSpace(pathText),
 		Page:      page,
-		Alt:       "", // TODO
+		Alt:       "", // filled in later by mergeAlt
 	}, nil
 }
 
@@ -210,7 +216,7 @@ func alt(htmlPath string) error {
 			if err != nil {
 				return err
 			}
-			imgs := findImgs(p.HTML)
+			imgs := findImgsHTML(p.HTML)
 			p.Imgs = imgs
 			p.HTML = ""
 			out, err := json.Marshal(p)
@@ -228,8 +234,10 @@ func alt(htmlPath string) error {
 }
 
 type img struct {
-	Src string
-	Alt string
+	Src      string
+	Alt      string
+	Title    string `json:",omitempty"`
+	Longdesc string `json:",omitempty"`
 }
 
 type htmlpayload struct {
@@ -239,85 +247,6 @@ type htmlpayload struct {
 	Imgs      []img
 }
 
-func findImgs(s string) []img {
-	out := []img{}
-	imgTagRe := regexp.MustCompile(`^\s*?img`)
-	tagBuff := ""
-	inTag := false
-	end := len(s)
-	pos := 0
-	for true {
-		if pos >= end {
-			break
-		}
-
-		if inTag {
-			tagBuff = tagBuff + string(s[pos])
-		}
-
-		if s[pos] == '<' {
-			inTag = true
-		}
-
-		if s[pos] == '>' {
-			inTag = false
-			lowered := strings.ToLower(tagBuff)
-			//if strings.Contains(lowered, "img") {
-			//	fmt.Printf("TAGBUFF: '%s'\n", tagBuff)
-			//	fmt.Printf("%v\n", imgTagRe.MatchString(lowered))
-			//	fmt.Printf("%v\n", strings.Contains(lowered, ".gif"))
-			//	fmt.Printf("%v\n", strings.Contains(lowered, "alt"))
-			//}
-			if imgTagRe.MatchString(lowered) && strings.Contains(lowered, ".gif") && strings.Contains(lowered, "alt") {
-				//fmt.Println("YEEHAW")
-				alt := extractProp(tagBuff, "alt")
-				src := extractProp(tagBuff, "src")
-				//fmt.Printf("alt '%s' src '%s'\n", alt, src)
-				if alt != "" || src != "" {
-					out = append(out, img{Src: src, Alt: alt})
-				}
-			}
-			tagBuff = ""
-		}
-
-		pos++
-	}
-	return out
-}
-
-var propExtractSingRe = regexp.MustCompile(`'([^']+)'`)
-var propExtractDoubRe = regexp.MustCompile(`"([^"]+)"`)
-
-func extractProp(s, prop string) string {
-	propIx := strings.Index(s, prop)
-	if propIx < 0 {
-		return ""
-	}
-	start := propIx + len(prop)
-	if start > len(s)-1 {
-		return ""
-	}
-	var re *regexp.Regexp
-	for x := start; x < len(s); x++ {
-		if string(s[x]) == `"` {
-			re = propExtractDoubRe
-			break
-		}
-		if string(s[x]) == "'" {
-			re = propExtractSingRe
-			break
-		}
-	}
-	if re == nil {
-		return ""
-	}
-	matches := re.FindStringSubmatch(s[start:])
-	if len(matches) < 2 {
-		return ""
-	}
-	return matches[1]
-}
-
 func eximg() error {
 	f, err := os.Open("gifs_jsonl-00000")
 	if err != nil {
@@ -437,17 +366,205 @@ func missing(missingJSONLPath, gifsDir string) error {
 	return nil
 }
 
-func uploadRaw(gifsDir string) error {
+// uploadJob is one unit of work handed from a producer goroutine to the
+// upload worker pool.
+type uploadJob struct {
+	Hash  string
+	Bytes []byte
+}
+
+// uploadOutcome is what a worker reports back to the collector for a single
+// uploadJob.
+type uploadOutcome struct {
+	Hash   string
+	Status string // "uploaded", "skipped", or "failed"
+	Err    error
+}
+
+// newShutdownContext derives a cancelable context from parent that's
+// canceled on the first SIGINT/SIGTERM so in-flight work can wind down
+// cleanly. A second signal exits the process immediately. Callers must
+// invoke the returned stop func once they're done listening.
+func newShutdownContext(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+	sigc := make(chan os.Signal, 2)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-sigc:
+		case <-done:
+			return
+		}
+		fmt.Fprintln(os.Stderr, "\nshutting down after in-flight uploads finish (press again to abort hard)...")
+		cancel()
+		select {
+		case <-sigc:
+			fmt.Fprintln(os.Stderr, "second signal received, aborting hard")
+			os.Exit(130)
+		case <-done:
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(sigc)
+		close(done)
+	}
+}
+
+// isTransientS3Error reports whether err is worth retrying: throttling,
+// timeouts, or anything that isn't a well-formed S3 error response at all
+// (i.e. a transport-level failure).
+func isTransientS3Error(err error) bool {
+	resp := minio.ToErrorResponse(err)
+	switch resp.Code {
+	case "", "InternalError", "SlowDown", "RequestTimeout", "ServiceUnavailable":
+		return true
+	}
+	return false
+}
+
+// uploadBackoff returns the jittered exponential backoff to wait before
+// retry attempt (1-indexed: the first retry, not the initial attempt).
+func uploadBackoff(attempt int) time.Duration {
+	backoff := time.Duration(1<<uint(attempt)) * 250 * time.Millisecond
+	backoff += time.Duration(rand.Int63n(int64(250 * time.Millisecond)))
+	return backoff
+}
+
+// putWithRetry calls PutObject, retrying transient errors with jittered
+// exponential backoff. It gives up immediately on a non-transient error or
+// if ctx is canceled while waiting.
+func putWithRetry(ctx context.Context, s3c *minio.Client, bucket, key string, bs []byte, opts minio.PutObjectOptions, maxAttempts int) (minio.UploadInfo, error) {
+	var lastErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			backoff := uploadBackoff(attempt)
+			select {
+			case <-ctx.Done():
+				return minio.UploadInfo{}, ctx.Err()
+			case <-time.After(backoff):
+			}
+		}
+		info, err := s3c.PutObject(ctx, bucket, key, bytes.NewReader(bs), int64(len(bs)), opts)
+		if err == nil {
+			return info, nil
+		}
+		lastErr = err
+		if !isTransientS3Error(err) {
+			return minio.UploadInfo{}, err
+		}
+	}
+	return minio.UploadInfo{}, fmt.Errorf("giving up on '%s' after %d attempts: %w", key, maxAttempts, lastErr)
+}
+
+// loadResumeLog reads a hashes.log written by a previous upload run and
+// returns the set of hashes that were already uploaded or confirmed present,
+// so a resumed run can skip them without a StatObject round-trip.
+func loadResumeLog(logPath string) (map[string]bool, error) {
+	done := map[string]bool{}
+	f, err := os.Open(logPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.SplitN(s.Text(), "\t", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		if strings.Contains(fields[0], "success") || strings.Contains(fields[0], "skip") {
+			done[strings.TrimSpace(fields[1])] = true
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %w", logPath, err)
+	}
+	return done, nil
+}
+
+// runUploadWorkers spins up a pool of parallel workers that drain jobs,
+// stat-then-put each one against dest, and report an outcome per job on the
+// returned channel, which is closed once every worker has exited. dest may
+// be an S3Store, an FSStore, or a multiStore fanning out to both.
+func runUploadWorkers(ctx context.Context, parallel int, jobs <-chan uploadJob, dest Store) <-chan uploadOutcome {
+	out := make(chan uploadOutcome)
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if ctx.Err() != nil {
+					out <- uploadOutcome{Hash: job.Hash, Status: "failed", Err: ctx.Err()}
+					continue
+				}
+				exists, err := dest.Stat(ctx, job.Hash)
+				if err != nil {
+					out <- uploadOutcome{Hash: job.Hash, Status: "failed", Err: err}
+					continue
+				}
+				if exists {
+					out <- uploadOutcome{Hash: job.Hash, Status: "skipped"}
+					continue
+				}
+				if err := dest.Put(ctx, job.Hash, job.Bytes); err != nil {
+					out <- uploadOutcome{Hash: job.Hash, Status: "failed", Err: err}
+					continue
+				}
+				out <- uploadOutcome{Hash: job.Hash, Status: "uploaded"}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// collectUploadOutcomes drains outcomes off a worker pool, updating bar and
+// hashLog as they arrive, and returns the final tallies.
+func collectUploadOutcomes(outcomes <-chan uploadOutcome, bar *pb.ProgressBar, hashLog *log.Logger) (uploaded, skipped, failed int) {
+	for o := range outcomes {
+		switch o.Status {
+		case "uploaded":
+			uploaded++
+			hashLog.Printf("success\t%s\n", o.Hash)
+		case "skipped":
+			skipped++
+			hashLog.Printf("skip\t%s\n", o.Hash)
+		case "failed":
+			failed++
+			hashLog.Printf("fail\t%s\t%s\n", o.Hash, o.Err)
+		}
+		bar.Increment()
+	}
+	return
+}
+
+func uploadRaw(gifsDir string, parallel int, resume bool, dest Store) error {
 	// this code is a highly specific script for dealing with the 4kish gifs I
 	// had to fetch from live wayback. The goal is to upload every gif in a given
 	// directory to seaweed using its filename (a hash) as a key.
 
-	s3c, err := newS3Client()
+	lFile, err := os.OpenFile("hashes.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		return err
 	}
-	if err = ensureBucket(s3c, bucket); err != nil {
-		return err
+	defer lFile.Close()
+	hashLog := log.New(lFile, "", log.Lshortfile)
+
+	resumeSet := map[string]bool{}
+	if resume {
+		resumeSet, err = loadResumeLog("hashes.log")
+		if err != nil {
+			return fmt.Errorf("failed to load resume log: %w", err)
+		}
 	}
 
 	entries, err := os.ReadDir(gifsDir)
@@ -455,49 +572,50 @@ func uploadRaw(gifsDir string) error {
 		return err
 	}
 
-	putOpts := minio.PutObjectOptions{
-		ContentType: "image/gif",
-	}
-	statOpts := minio.StatObjectOptions{}
+	ctx, stop := newShutdownContext(context.Background())
+	defer stop()
 
-	total := float64(len(entries))
-	uploads := 0
-	prevSeen := 0
-	readFiles := 0
+	bar := pb.StartNew(len(entries))
+	jobs := make(chan uploadJob, parallel*2)
 
-	for x, entry := range entries {
-		key := entry.Name()
-		gf, err := os.Open(path.Join(gifsDir, key))
-		if err != nil {
-			return err
-		}
-		defer gf.Close()
-		bs, err := io.ReadAll(gf)
-		if err != nil {
-			return err
-		}
-		ctx := context.Background()
-		readFiles++
-		fmt.Printf("\033[2K\r%d/%f gif files | %d gifs read | %d prevSeen | %d uploads",
-			x+1, total, readFiles, prevSeen, uploads)
-		_, err = s3c.StatObject(ctx, bucket, key, statOpts)
-		if err == nil {
-			prevSeen++
-			continue
-		}
-		info, err := s3c.PutObject(ctx, bucket, key, bytes.NewReader(bs), int64(len(bs)), putOpts)
-		if err != nil {
-			return fmt.Errorf("put object failed for '%s': %w", key, err)
-		}
-		uploads++
-		if info.Bucket != bucket {
-			return fmt.Errorf("[put] bucket mismatch: %v", info.Bucket)
-		}
-		if info.Key != key {
-			return fmt.Errorf("[put] key mismatch: %v", info.Key)
+	go func() {
+		defer close(jobs)
+		for _, entry := range entries {
+			if ctx.Err() != nil {
+				return
+			}
+			key := entry.Name()
+			if resumeSet[key] {
+				bar.Increment()
+				continue
+			}
+			gf, err := os.Open(path.Join(gifsDir, key))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to open '%s': %s\n", key, err)
+				continue
+			}
+			bs, err := io.ReadAll(gf)
+			gf.Close()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to read '%s': %s\n", key, err)
+				continue
+			}
+			select {
+			case jobs <- uploadJob{Hash: key, Bytes: bs}:
+			case <-ctx.Done():
+				return
+			}
 		}
-	}
+	}()
+
+	outcomes := runUploadWorkers(ctx, parallel, jobs, dest)
+	uploaded, skipped, failed := collectUploadOutcomes(outcomes, bar, hashLog)
+	bar.Finish()
 
+	fmt.Printf("uploaded %d | skipped %d | failed %d\n", uploaded, skipped, failed)
+	if ctx.Err() != nil {
+		return fmt.Errorf("interrupted: %w", ctx.Err())
+	}
 	return nil
 }
 
@@ -544,119 +662,131 @@ func ensureBucket(s3c *minio.Client, bucket string) error {
 	return nil
 }
 
-func upload(encodedPath string) error {
-	// This code is only intended to be run from aitio
-	lFile, err := os.Create("hashes.log")
+// encodedGif is one line of the gzipped jsonl shards produced by the spark
+// job: a checksum plus the base64-encoded gif bytes.
+type encodedGif struct {
+	Hash   string
+	Gifb64 string
+}
+
+// decodeEncodedShard reads every line of a gzipped jsonl shard and hands
+// each decoded job to emit. It stops early if ctx is canceled.
+func decodeEncodedShard(ctx context.Context, shardPath string, emit func(uploadJob) bool) error {
+	f, err := os.Open(shardPath)
 	if err != nil {
 		return err
 	}
-	hashLog := log.New(lFile, "", log.Lshortfile)
-	defer lFile.Close()
+	defer f.Close()
 
-	s3c, err := newS3Client()
+	zr, err := gzip.NewReader(f)
 	if err != nil {
-		return fmt.Errorf("failed to create s3 client: %w", err)
+		return err
 	}
 
-	if err = ensureBucket(s3c, bucket); err != nil {
+	s := bufio.NewScanner(zr)
+	buf := make([]byte, 0, 24*1024*1024)
+	s.Buffer(buf, 24*1024*1024)
+
+	for s.Scan() {
+		if ctx.Err() != nil {
+			return nil
+		}
+		p := encodedGif{}
+		if err := json.Unmarshal(s.Bytes(), &p); err != nil {
+			return fmt.Errorf("failed to decode line in '%s': %w", shardPath, err)
+		}
+		bs, err := base64.StdEncoding.DecodeString(p.Gifb64)
+		if err != nil {
+			return fmt.Errorf("failed to decode gifb64 for '%s': %w", p.Hash, err)
+		}
+		if !emit(uploadJob{Hash: p.Hash, Bytes: bs}) {
+			return nil
+		}
+	}
+	return s.Err()
+}
+
+func upload(encodedPath string, parallel int, resume bool, dest Store) error {
+	// This code is only intended to be run from aitio
+	lFile, err := os.OpenFile("hashes.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
 		return err
 	}
+	defer lFile.Close()
+	hashLog := log.New(lFile, "", log.Lshortfile)
+
+	resumeSet := map[string]bool{}
+	if resume {
+		resumeSet, err = loadResumeLog("hashes.log")
+		if err != nil {
+			return fmt.Errorf("failed to load resume log: %w", err)
+		}
+	}
 
 	entries, err := os.ReadDir(encodedPath)
 	if err != nil {
 		return fmt.Errorf("could not read jsonl dir '%s': %w", encodedPath, err)
 	}
 
-	total := 0.0
+	shards := []string{}
 	for _, e := range entries {
 		if strings.HasSuffix(e.Name(), ".gz") {
-			total++
+			shards = append(shards, path.Join(encodedPath, e.Name()))
 		}
 	}
 
-	putOpts := minio.PutObjectOptions{
-		ContentType: "image/gif",
-	}
-	statOpts := minio.StatObjectOptions{}
-
-	uploads := 0
-	prevSeen := 0
-	readLines := 0
-
-	for x, entry := range entries {
-		if !strings.HasSuffix(entry.Name(), ".gz") {
-			continue
-		}
-		f, err := os.Open(path.Join(encodedPath, entry.Name()))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		zr, err := gzip.NewReader(f)
-		if err != nil {
-			return err
-		}
-
-		s := bufio.NewScanner(zr)
+	ctx, stop := newShutdownContext(context.Background())
+	defer stop()
 
-		type encodedGif struct {
-			Hash   string
-			Gifb64 string
+	// A precise total needs a full pass over the shards, which is cheap next
+	// to the upload itself, so the bar can show real progress instead of a
+	// spinner.
+	total := 0
+	for _, shard := range shards {
+		if err := decodeEncodedShard(ctx, shard, func(j uploadJob) bool {
+			if !resumeSet[j.Hash] {
+				total++
+			}
+			return ctx.Err() == nil
+		}); err != nil {
+			return fmt.Errorf("failed counting '%s': %w", shard, err)
 		}
+	}
 
-		buf := make([]byte, 0, 24*1024*1024)
-		s.Buffer(buf, 24*1024*1024)
-
-		ctx := context.Background()
+	bar := pb.StartNew(total)
+	jobs := make(chan uploadJob, parallel*2)
 
-		for s.Scan() {
-			line := s.Text()
-			readLines++
-			fmt.Printf("\033[2K\r%d/%f .gz files | %d lines read | %d prevSeen | %d uploads",
-				x+1, total, readLines, prevSeen, uploads)
-			p := encodedGif{}
-			err := json.Unmarshal([]byte(line), &p)
-			if err != nil {
-				return err
-			}
-			_, err = s3c.StatObject(ctx, bucket, p.Hash, statOpts)
-			if err == nil {
-				prevSeen++
-				hashLog.Printf("skip\t%s\n", p.Hash)
-				// TODO could check size of object and see if it matches
-				continue
+	go func() {
+		defer close(jobs)
+		for _, shard := range shards {
+			if ctx.Err() != nil {
+				return
 			}
-			bs, err := base64.StdEncoding.DecodeString(p.Gifb64)
-			if err != nil {
-				return err
-			}
-			info, err := s3c.PutObject(ctx, bucket, p.Hash, bytes.NewReader(bs), int64(len(bs)), putOpts)
+			err := decodeEncodedShard(ctx, shard, func(j uploadJob) bool {
+				if resumeSet[j.Hash] {
+					return true
+				}
+				select {
+				case jobs <- j:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			})
 			if err != nil {
-				hashLog.Printf("fail\t%s\n", p.Hash)
-				return fmt.Errorf("put object failed for '%s': %w", p.Hash, err)
+				fmt.Fprintf(os.Stderr, "failed to decode '%s': %s\n", shard, err)
 			}
-			hashLog.Printf("success\t%s\n", p.Hash)
-			uploads++
-			// TODO how likely are these cases?
-			if info.Bucket != bucket {
-				return fmt.Errorf("[put] bucket mismatch: %v", info.Bucket)
-			}
-			if info.Key != p.Hash {
-				return fmt.Errorf("[put] key mismatch: %v", info.Key)
-			}
-		}
-		err = s.Err()
-		if err != nil {
-			return err
 		}
-	}
+	}()
 
-	fmt.Println()
-	fmt.Println()
-	fmt.Printf("ignored %d seen hashes\n", prevSeen)
-	fmt.Printf("uploaded %d gifs\n", uploads)
+	outcomes := runUploadWorkers(ctx, parallel, jobs, dest)
+	uploaded, skipped, failed := collectUploadOutcomes(outcomes, bar, hashLog)
+	bar.Finish()
 
+	fmt.Printf("uploaded %d | skipped %d | failed %d\n", uploaded, skipped, failed)
+	if ctx.Err() != nil {
+		return fmt.Errorf("interrupted: %w", ctx.Err())
+	}
 	return nil
 }
 
@@ -960,7 +1090,17 @@ func main() {
 
 	switch os.Args[1] {
 	case "upload":
-		err = upload(encodedPath)
+		fs := flag.NewFlagSet("upload", flag.ExitOnError)
+		parallel := fs.Int("parallel", 8, "number of concurrent upload workers")
+		resume := fs.Bool("resume", false, "skip hashes already marked success or skip in hashes.log")
+		store := fs.String("store", "s3", "where to upload to: s3, fs, or both")
+		mirrorRoot := fs.String("mirror-root", "./data/mirror", "root of the local mirror, used when -store is fs or both")
+		fs.Parse(os.Args[2:])
+		var dest Store
+		dest, err = resolveStore(*store, *mirrorRoot)
+		if err == nil {
+			err = upload(encodedPath, *parallel, *resume, dest)
+		}
 	case "manifest":
 		mp := manifestPath
 		if len(os.Args) == 3 {
@@ -969,12 +1109,32 @@ func main() {
 		err = manifest(mp)
 	case "alt":
 		err = alt(htmlPath)
+	case "mergealt":
+		altPath := "./data/gifpages_alt.jsonl"
+		outPath := altMergedPath
+		if len(os.Args) >= 3 {
+			altPath = os.Args[2]
+		}
+		if len(os.Args) >= 4 {
+			outPath = os.Args[3]
+		}
+		err = mergeAlt(jsonlPath, altPath, outPath)
 	case "eximg":
 		err = eximg()
 	case "missing":
 		err = missing("./data/gifcities.jsonl", "./data/missing")
 	case "uploadRaw":
-		err = uploadRaw("./data/missing")
+		fs := flag.NewFlagSet("uploadRaw", flag.ExitOnError)
+		parallel := fs.Int("parallel", 8, "number of concurrent upload workers")
+		resume := fs.Bool("resume", false, "skip hashes already marked success or skip in hashes.log")
+		store := fs.String("store", "s3", "where to upload to: s3, fs, or both")
+		mirrorRoot := fs.String("mirror-root", "./data/mirror", "root of the local mirror, used when -store is fs or both")
+		fs.Parse(os.Args[2:])
+		var dest Store
+		dest, err = resolveStore(*store, *mirrorRoot)
+		if err == nil {
+			err = uploadRaw("./data/missing", *parallel, *resume, dest)
+		}
 	case "vecmerge":
 		vp := vecPath
 		if len(os.Args) == 3 {
@@ -985,6 +1145,99 @@ func main() {
 		err = extractSparkUnique()
 	case "fixmanifest":
 		err = fixmanifest()
+	case "fetch":
+		fs := flag.NewFlagSet("fetch", flag.ExitOnError)
+		parallel := fs.Int("concurrency", 4, "number of concurrent fetch workers")
+		hostDelay := fs.Duration("host-delay", 500*time.Millisecond, "politeness delay between requests to the same host")
+		imgurToken := fs.String("imgur-token", "", "imgur API token; enables album expansion in the live-host fetcher")
+		out := fs.String("out", "./data/livewayback.jsonl", "where to write fetched soLines")
+		store := fs.String("store", "fs", "where to check for already-present gifs before fetching: s3, fs, or both")
+		mirrorRoot := fs.String("mirror-root", "./data/mirror", "root of the local mirror, used when -store is fs or both")
+		fs.Parse(os.Args[2:])
+
+		var present Store
+		present, err = resolveStore(*store, *mirrorRoot)
+		if err == nil {
+			fetcher := chainFetcher{
+				WaybackDirectFetcher{},
+				WaybackClosestFetcher{},
+				LiveHostFetcher{ImgurToken: *imgurToken},
+			}
+			err = fetchMissing(jsonlPath, *out, present, fetcher, *parallel, *hostDelay)
+		}
+	case "export":
+		fs := flag.NewFlagSet("export", flag.ExitOnError)
+		checksumList := fs.String("checksum-list", "", "file of one checksum per line to restrict the export to")
+		minUses := fs.Int("min-uses", 0, "only export gifs seen at least this many times")
+		includeNSFW := fs.Bool("nsfw", true, "set -nsfw=false to exclude gifs flagged knsfw")
+		withVecs := fs.Bool("with-vecs", false, "include embedding vectors in the exported manifest")
+		out := fs.String("out", "", "file to write the tar.gz bundle to; defaults to stdout")
+		store := fs.String("store", "s3", "where to pull gif bytes from: s3, fs, or both")
+		mirrorRoot := fs.String("mirror-root", "./data/mirror", "root of the local mirror, used when -store is fs or both")
+		fs.Parse(os.Args[2:])
+
+		filt := exportFilter{MinUses: *minUses, NoNSFW: !*includeNSFW, WithVecs: *withVecs}
+		if *checksumList != "" {
+			filt.Checksums, err = loadChecksumList(*checksumList)
+		}
+		if err == nil {
+			var src Store
+			src, err = resolveStore(*store, *mirrorRoot)
+			if err == nil {
+				w := io.Writer(os.Stdout)
+				if *out != "" {
+					var outf *os.File
+					outf, err = os.Create(*out)
+					if err == nil {
+						defer outf.Close()
+						w = outf
+					}
+				}
+				if err == nil {
+					err = exportBundle(mergedVecPath, filt, src, w)
+				}
+			}
+		}
+	case "verify":
+		fs := flag.NewFlagSet("verify", flag.ExitOnError)
+		parallel := fs.Int("parallel", 8, "number of concurrent verify workers")
+		maxBytes := fs.Int64("max-bytes", 0, "reject gifs over this many bytes; 0 disables the cap")
+		repair := fs.Bool("repair", false, "re-fetch and rewrite gifs that fail verification")
+		reportPath := fs.String("report", "./data/verify_report.jsonl", "where to write the JSONL mismatch report")
+		mergedManifest := fs.Bool("merged", false, "read gifcities_vec.jsonl instead of gifcities.jsonl")
+		store := fs.String("store", "s3", "where to verify against: s3, fs, or both")
+		mirrorRoot := fs.String("mirror-root", "./data/mirror", "root of the local mirror, used when -store is fs or both")
+		imgurToken := fs.String("imgur-token", "", "imgur API token, passed to the live-host fetcher used by -repair")
+		fs.Parse(os.Args[2:])
+
+		mp := jsonlPath
+		if *mergedManifest {
+			mp = mergedVecPath
+		}
+		var src Store
+		src, err = resolveStore(*store, *mirrorRoot)
+		if err == nil {
+			fetcher := chainFetcher{
+				WaybackDirectFetcher{},
+				WaybackClosestFetcher{},
+				LiveHostFetcher{ImgurToken: *imgurToken},
+			}
+			err = verifyCorpus(mp, *reportPath, src, *parallel, *maxBytes, *repair, fetcher)
+		}
+	case "mirror":
+		fs := flag.NewFlagSet("mirror", flag.ExitOnError)
+		root := fs.String("root", "./data/mirror", "root directory of the content-addressable mirror")
+		fromS3 := fs.Bool("from-s3", false, "pull gifs from the existing S3 bucket instead of the encoded shards")
+		fs.Parse(os.Args[2:])
+		if *fromS3 {
+			var s3c *minio.Client
+			s3c, err = newS3Client()
+			if err == nil {
+				err = MirrorFromS3(s3c, *root)
+			}
+		} else {
+			err = MirrorFromEncoded(encodedPath, *root)
+		}
 	default:
 		fmt.Fprintln(os.Stderr, "unknown subcommand")
 		os.Exit(3)
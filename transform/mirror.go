@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+const mirrorDirPerm = 0755
+
+// Store is anywhere a gif's bytes can be put, stat'd, or fetched by
+// checksum. upload and the mirror subcommand can both target it, whether
+// that's the seaweed/S3 bucket or a local content-addressable tree.
+type Store interface {
+	Put(ctx context.Context, hash string, bs []byte) error
+	Stat(ctx context.Context, hash string) (bool, error)
+	Get(ctx context.Context, hash string) ([]byte, error)
+}
+
+// FSStore is a Store backed by a content-addressable directory tree rooted
+// at Root: content/<xx>/<hash>.gif, sharded by the first two characters of
+// the lowercased checksum (see shardPrefix), with a
+// parallel date/YYYY/MM/<hash>.gif tree of hardlinks keyed by first-seen
+// use timestamp.
+type FSStore struct {
+	Root string
+}
+
+// NewFSStore returns a Store rooted at root. Shard directories are created
+// lazily by Put/LinkDate as checksums land in them, since Gif.Checksum is
+// base32 (see checksumFor), not hex — the shard alphabet is too wide to
+// usefully pre-create up front.
+func NewFSStore(root string) (*FSStore, error) {
+	return &FSStore{Root: root}, nil
+}
+
+// shardPrefix is the two-char directory a checksum shards under, both in
+// the local mirror and in export bundles.
+func shardPrefix(hash string) string {
+	lowered := strings.ToLower(hash)
+	if len(lowered) < 2 {
+		return "00"
+	}
+	return lowered[:2]
+}
+
+func (fs *FSStore) contentPath(hash string) string {
+	return path.Join(fs.Root, "content", shardPrefix(hash), hash+".gif")
+}
+
+func (fs *FSStore) Put(ctx context.Context, hash string, bs []byte) error {
+	dest := fs.contentPath(hash)
+	if err := os.MkdirAll(path.Dir(dest), mirrorDirPerm); err != nil {
+		return fmt.Errorf("failed to create '%s': %w", path.Dir(dest), err)
+	}
+	tmp := dest + ".tmp"
+	if err := os.WriteFile(tmp, bs, 0644); err != nil {
+		return fmt.Errorf("failed to write temp file for '%s': %w", hash, err)
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to rename '%s' into place: %w", hash, err)
+	}
+	return nil
+}
+
+func (fs *FSStore) Stat(ctx context.Context, hash string) (bool, error) {
+	_, err := os.Stat(fs.contentPath(hash))
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+func (fs *FSStore) Get(ctx context.Context, hash string) ([]byte, error) {
+	return os.ReadFile(fs.contentPath(hash))
+}
+
+// LinkDate hardlinks hash's content file into date/YYYY/MM/<hash>.gif, keyed
+// off a wayback timestamp like "20031224055733". It's a no-op if the link
+// already exists.
+func (fs *FSStore) LinkDate(hash, timestamp string) error {
+	if len(timestamp) < 6 {
+		return fmt.Errorf("timestamp '%s' too short to derive year/month", timestamp)
+	}
+	year, month := timestamp[:4], timestamp[4:6]
+	dir := path.Join(fs.Root, "date", year, month)
+	if err := os.MkdirAll(dir, mirrorDirPerm); err != nil {
+		return fmt.Errorf("failed to create '%s': %w", dir, err)
+	}
+	dest := path.Join(dir, hash+".gif")
+	if _, err := os.Stat(dest); err == nil {
+		return nil
+	}
+	if err := os.Link(fs.contentPath(hash), dest); err != nil {
+		return fmt.Errorf("failed to link '%s' into date tree: %w", hash, err)
+	}
+	return nil
+}
+
+// S3Store is a Store backed by the seaweed/S3 bucket used elsewhere in this
+// package.
+type S3Store struct {
+	Client *minio.Client
+	Bucket string
+}
+
+// NewS3Store builds a Store over the bucket shared by the rest of this
+// package, creating it if it doesn't already exist.
+func NewS3Store() (*S3Store, error) {
+	s3c, err := newS3Client()
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureBucket(s3c, bucket); err != nil {
+		return nil, err
+	}
+	return &S3Store{Client: s3c, Bucket: bucket}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, hash string, bs []byte) error {
+	opts := minio.PutObjectOptions{ContentType: "image/gif"}
+	info, err := putWithRetry(ctx, s.Client, s.Bucket, hash, bs, opts, 5)
+	if err != nil {
+		return err
+	}
+	if info.Key != hash {
+		return fmt.Errorf("[put] key mismatch: %v", info.Key)
+	}
+	return nil
+}
+
+func (s *S3Store) Stat(ctx context.Context, hash string) (bool, error) {
+	_, err := s.Client.StatObject(ctx, s.Bucket, hash, minio.StatObjectOptions{})
+	if err == nil {
+		return true, nil
+	}
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return false, nil
+	}
+	return false, err
+}
+
+func (s *S3Store) Get(ctx context.Context, hash string) ([]byte, error) {
+	obj, err := s.Client.GetObject(ctx, s.Bucket, hash, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	return io.ReadAll(obj)
+}
+
+// multiStore fans Put out to every store it wraps, and only considers a
+// hash present once every wrapped store already has it. It's how upload
+// targets S3 and the local mirror at the same time.
+type multiStore struct {
+	stores []Store
+}
+
+func (m multiStore) Put(ctx context.Context, hash string, bs []byte) error {
+	for _, s := range m.stores {
+		if err := s.Put(ctx, hash, bs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multiStore) Stat(ctx context.Context, hash string) (bool, error) {
+	for _, s := range m.stores {
+		ok, err := s.Stat(ctx, hash)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (m multiStore) Get(ctx context.Context, hash string) ([]byte, error) {
+	return m.stores[0].Get(ctx, hash)
+}
+
+// resolveStore builds the upload destination named by kind ("s3", "fs", or
+// "both"), using mirrorRoot for any filesystem-backed store.
+func resolveStore(kind, mirrorRoot string) (Store, error) {
+	switch kind {
+	case "s3":
+		return NewS3Store()
+	case "fs":
+		return NewFSStore(mirrorRoot)
+	case "both":
+		s3Store, err := NewS3Store()
+		if err != nil {
+			return nil, err
+		}
+		fsStore, err := NewFSStore(mirrorRoot)
+		if err != nil {
+			return nil, err
+		}
+		return multiStore{stores: []Store{s3Store, fsStore}}, nil
+	default:
+		return nil, fmt.Errorf("unknown store kind '%s': want s3, fs, or both", kind)
+	}
+}
+
+// loadGifsByHash reads a gifcities.jsonl-shaped file into a checksum-keyed
+// map, the same pattern vecmerge uses to load the same file.
+func loadGifsByHash(p string) (map[string]*Gif, error) {
+	gifs := map[string]*Gif{}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	buf := make([]byte, 0, 24*1024*1024)
+	s.Buffer(buf, 24*1024*1024)
+	for s.Scan() {
+		gif := Gif{}
+		if err := json.Unmarshal(s.Bytes(), &gif); err != nil {
+			return nil, err
+		}
+		gifs[gif.Checksum] = &gif
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return gifs, nil
+}
+
+// mirrorOne writes bs into dest and, when gifsByHash has use metadata for
+// hash, hardlinks it into the date tree too.
+func mirrorOne(ctx context.Context, dest *FSStore, gifsByHash map[string]*Gif, hash string, bs []byte) error {
+	if err := dest.Put(ctx, hash, bs); err != nil {
+		return err
+	}
+	if gif, ok := gifsByHash[hash]; ok && len(gif.Uses) > 0 {
+		if err := dest.LinkDate(hash, gif.Uses[0].Timestamp); err != nil {
+			fmt.Fprintf(os.Stderr, "WARN failed to date-link '%s': %s\n", hash, err)
+		}
+	}
+	return nil
+}
+
+// MirrorFromEncoded decodes every gzipped jsonl shard under encodedPath
+// (the same format upload reads) into root's content-addressable tree,
+// indexing each gif under date/ by its first recorded use.
+func MirrorFromEncoded(encodedPath, root string) error {
+	dest, err := NewFSStore(root)
+	if err != nil {
+		return err
+	}
+
+	gifsByHash, err := loadGifsByHash(jsonlPath)
+	if err != nil {
+		return fmt.Errorf("failed to load '%s': %w", jsonlPath, err)
+	}
+
+	entries, err := os.ReadDir(encodedPath)
+	if err != nil {
+		return fmt.Errorf("could not read jsonl dir '%s': %w", encodedPath, err)
+	}
+
+	ctx := context.Background()
+	mirrored := 0
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".gz") {
+			continue
+		}
+		if err := func() error {
+			f, err := os.Open(path.Join(encodedPath, e.Name()))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			zr, err := gzip.NewReader(f)
+			if err != nil {
+				return err
+			}
+			s := bufio.NewScanner(zr)
+			buf := make([]byte, 0, 24*1024*1024)
+			s.Buffer(buf, 24*1024*1024)
+
+			for s.Scan() {
+				p := encodedGif{}
+				if err := json.Unmarshal(s.Bytes(), &p); err != nil {
+					return fmt.Errorf("failed to decode line in '%s': %w", e.Name(), err)
+				}
+				bs, err := base64.StdEncoding.DecodeString(p.Gifb64)
+				if err != nil {
+					return fmt.Errorf("failed to decode gifb64 for '%s': %w", p.Hash, err)
+				}
+				if err := mirrorOne(ctx, dest, gifsByHash, p.Hash, bs); err != nil {
+					return err
+				}
+				mirrored++
+			}
+			return s.Err()
+		}(); err != nil {
+			return fmt.Errorf("shard '%s' failed: %w", e.Name(), err)
+		}
+	}
+
+	fmt.Printf("mirrored %d gifs into '%s'\n", mirrored, root)
+	return nil
+}
+
+// MirrorFromS3 pulls every gif referenced in gifcities.jsonl out of the
+// existing S3 bucket and writes it into root's content-addressable tree.
+func MirrorFromS3(s3c *minio.Client, root string) error {
+	dest, err := NewFSStore(root)
+	if err != nil {
+		return err
+	}
+
+	gifsByHash, err := loadGifsByHash(jsonlPath)
+	if err != nil {
+		return fmt.Errorf("failed to load '%s': %w", jsonlPath, err)
+	}
+
+	src := &S3Store{Client: s3c, Bucket: bucket}
+	ctx := context.Background()
+	mirrored := 0
+	for hash := range gifsByHash {
+		bs, err := src.Get(ctx, hash)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARN failed to fetch '%s' from s3: %s\n", hash, err)
+			continue
+		}
+		if err := mirrorOne(ctx, dest, gifsByHash, hash, bs); err != nil {
+			return err
+		}
+		mirrored++
+	}
+
+	fmt.Printf("mirrored %d gifs into '%s'\n", mirrored, root)
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func Test_shardPrefix(t *testing.T) {
+	cases := []struct {
+		name   string
+		hash   string
+		expect string
+	}{
+		{name: "real base32 checksum", hash: "MA2RY6GRLVEBI5AJ5EUGLQUEECB3GS3V", expect: "ma"},
+		{name: "lowercase input", hash: "abcdef", expect: "ab"},
+		{name: "too short", hash: "a", expect: "00"},
+		{name: "empty", hash: "", expect: "00"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result := shardPrefix(c.hash)
+			if result != c.expect {
+				t.Errorf("expected '%s', got '%s'", c.expect, result)
+			}
+		})
+	}
+}
+
+func Test_FSStore_PutGet_base32Checksum(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewFSStore(root)
+	if err != nil {
+		t.Fatalf("NewFSStore failed: %s", err)
+	}
+
+	ctx := context.Background()
+	hash := "MA2RY6GRLVEBI5AJ5EUGLQUEECB3GS3V"
+	want := []byte("GIF89a")
+
+	if err := store.Put(ctx, hash, want); err != nil {
+		t.Fatalf("Put failed: %s", err)
+	}
+
+	exists, err := store.Stat(ctx, hash)
+	if err != nil {
+		t.Fatalf("Stat failed: %s", err)
+	}
+	if !exists {
+		t.Fatal("expected Stat to report the gif as present after Put")
+	}
+
+	got, err := store.Get(ctx, hash)
+	if err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("expected '%s', got '%s'", want, got)
+	}
+
+	if _, err := os.Stat(store.contentPath(hash)); err != nil {
+		t.Errorf("expected content file to exist on disk: %s", err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func Test_isTransientS3Error(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		expect bool
+	}{
+		{name: "transport-level failure with no S3 error response", err: errors.New("connection reset"), expect: true},
+		{name: "internal error", err: minio.ErrorResponse{Code: "InternalError"}, expect: true},
+		{name: "slow down", err: minio.ErrorResponse{Code: "SlowDown"}, expect: true},
+		{name: "request timeout", err: minio.ErrorResponse{Code: "RequestTimeout"}, expect: true},
+		{name: "service unavailable", err: minio.ErrorResponse{Code: "ServiceUnavailable"}, expect: true},
+		{name: "access denied is not transient", err: minio.ErrorResponse{Code: "AccessDenied"}, expect: false},
+		{name: "no such key is not transient", err: minio.ErrorResponse{Code: "NoSuchKey"}, expect: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result := isTransientS3Error(c.err)
+			if result != c.expect {
+				t.Errorf("expected %v, got %v", c.expect, result)
+			}
+		})
+	}
+}
+
+func Test_uploadBackoff(t *testing.T) {
+	for attempt := 1; attempt <= 5; attempt++ {
+		min := time.Duration(1<<uint(attempt)) * 250 * time.Millisecond
+		max := min + 250*time.Millisecond
+		backoff := uploadBackoff(attempt)
+		if backoff < min || backoff >= max {
+			t.Errorf("attempt %d: expected backoff in [%s, %s), got %s", attempt, min, max, backoff)
+		}
+	}
+}
+
+func Test_loadResumeLog(t *testing.T) {
+	t.Run("missing file returns an empty set, not an error", func(t *testing.T) {
+		done, err := loadResumeLog(filepath.Join(t.TempDir(), "does-not-exist.log"))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(done) != 0 {
+			t.Errorf("expected an empty set, got %v", done)
+		}
+	})
+
+	t.Run("success and skip lines are resumable, fail lines are not", func(t *testing.T) {
+		p := filepath.Join(t.TempDir(), "hashes.log")
+		contents := "success\tAAAA\nskip\tBBBB\nfail\tCCCC\tsome error\nmalformed line with no tab\n"
+		if err := os.WriteFile(p, []byte(contents), 0644); err != nil {
+			t.Fatalf("failed to write fixture: %s", err)
+		}
+
+		done, err := loadResumeLog(p)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !done["AAAA"] || !done["BBBB"] {
+			t.Errorf("expected AAAA and BBBB to be marked done, got %v", done)
+		}
+		if done["CCCC"] {
+			t.Errorf("expected CCCC (a fail line) not to be marked done, got %v", done)
+		}
+		if len(done) != 2 {
+			t.Errorf("expected exactly 2 resumable hashes, got %v", done)
+		}
+	})
+}
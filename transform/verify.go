@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image/gif"
+	"os"
+	"sync"
+
+	"github.com/cheggaaa/pb/v3"
+)
+
+// verifyReason is a stable code for why a gif failed verification, so a
+// report can be grepped/aggregated without parsing free text.
+type verifyReason string
+
+const (
+	reasonFetchFailed       verifyReason = "fetch_failed"
+	reasonTooLarge          verifyReason = "too_large"
+	reasonChecksumMismatch  verifyReason = "checksum_mismatch"
+	reasonDecodeFailed      verifyReason = "decode_failed"
+	reasonDimensionMismatch verifyReason = "dimension_mismatch"
+	reasonZeroFrames        verifyReason = "zero_frames"
+)
+
+// verifyReport is one line of the -repair/verify JSONL output: a gif that
+// failed one of the checks, why, and whether -repair fixed it.
+type verifyReport struct {
+	Checksum string       `json:"checksum"`
+	Reason   verifyReason `json:"reason"`
+	Detail   string       `json:"detail,omitempty"`
+	Repaired bool         `json:"repaired,omitempty"`
+}
+
+// checkGif pulls a gif's bytes from src and confirms its checksum, that it
+// decodes as a gif with the recorded dimensions, and that it has at least
+// one frame and isn't over maxBytes (maxBytes <= 0 disables the cap). It
+// returns nil when everything checks out.
+func checkGif(ctx context.Context, g *Gif, src Store, maxBytes int64) (*verifyReport, []byte) {
+	bs, err := src.Get(ctx, g.Checksum)
+	if err != nil {
+		return &verifyReport{Checksum: g.Checksum, Reason: reasonFetchFailed, Detail: err.Error()}, nil
+	}
+	if maxBytes > 0 && int64(len(bs)) > maxBytes {
+		return &verifyReport{Checksum: g.Checksum, Reason: reasonTooLarge, Detail: fmt.Sprintf("%d bytes", len(bs))}, bs
+	}
+	if got := checksumFor(bs); got != g.Checksum {
+		return &verifyReport{Checksum: g.Checksum, Reason: reasonChecksumMismatch, Detail: fmt.Sprintf("got '%s'", got)}, bs
+	}
+	decoded, err := gif.DecodeAll(bytes.NewReader(bs))
+	if err != nil {
+		return &verifyReport{Checksum: g.Checksum, Reason: reasonDecodeFailed, Detail: err.Error()}, bs
+	}
+	if int32(decoded.Config.Width) != g.Width || int32(decoded.Config.Height) != g.Height {
+		return &verifyReport{
+			Checksum: g.Checksum,
+			Reason:   reasonDimensionMismatch,
+			Detail:   fmt.Sprintf("got %dx%d want %dx%d", decoded.Config.Width, decoded.Config.Height, g.Width, g.Height),
+		}, bs
+	}
+	if len(decoded.Image) == 0 {
+		return &verifyReport{Checksum: g.Checksum, Reason: reasonZeroFrames}, bs
+	}
+	return nil, bs
+}
+
+// repairGif re-fetches g via fetcher, and if the new bytes hash correctly,
+// writes them into src and reports success.
+func repairGif(ctx context.Context, g *Gif, src Store, fetcher Fetcher) error {
+	if len(g.Uses) == 0 {
+		return fmt.Errorf("no uses recorded to fetch from")
+	}
+	bs, err := fetcher.Fetch(ctx, g.Uses[0])
+	if err != nil {
+		return fmt.Errorf("refetch failed: %w", err)
+	}
+	if got := checksumFor(bs); got != g.Checksum {
+		return fmt.Errorf("refetched bytes still don't match: got '%s'", got)
+	}
+	if err := src.Put(ctx, g.Checksum, bs); err != nil {
+		return fmt.Errorf("failed to write repaired bytes: %w", err)
+	}
+	return nil
+}
+
+// verifyCorpus checks every gif in jsonlPath against src in parallel,
+// writing one verifyReport line per failure to reportPath. When repair is
+// true, failures are handed to fetcher and re-verified before being
+// reported, using the same cancelable-context/progress-bar infrastructure
+// as upload.
+func verifyCorpus(jsonlPath, reportPath string, src Store, parallel int, maxBytes int64, repair bool, fetcher Fetcher) error {
+	gifsByHash, err := loadGifsByHash(jsonlPath)
+	if err != nil {
+		return fmt.Errorf("failed to load '%s': %w", jsonlPath, err)
+	}
+
+	reportf, err := os.Create(reportPath)
+	if err != nil {
+		return err
+	}
+	defer reportf.Close()
+
+	ctx, stop := newShutdownContext(context.Background())
+	defer stop()
+
+	bar := pb.StartNew(len(gifsByHash))
+	jobs := make(chan *Gif, parallel*2)
+
+	go func() {
+		defer close(jobs)
+		for _, g := range gifsByHash {
+			select {
+			case jobs <- g:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	reports := make(chan verifyReport)
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for g := range jobs {
+				report, _ := checkGif(ctx, g, src, maxBytes)
+				if report != nil {
+					if repair {
+						if err := repairGif(ctx, g, src, fetcher); err != nil {
+							report.Detail += fmt.Sprintf(" (repair failed: %s)", err)
+						} else if rr, _ := checkGif(ctx, g, src, maxBytes); rr == nil {
+							report.Repaired = true
+						}
+					}
+					reports <- *report
+				}
+				bar.Increment()
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(reports)
+	}()
+
+	failed, repaired := 0, 0
+	for r := range reports {
+		obs, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("failed to serialize report for '%s': %w", r.Checksum, err)
+		}
+		fmt.Fprintf(reportf, "%s\n", obs)
+		failed++
+		if r.Repaired {
+			repaired++
+		}
+	}
+	bar.Finish()
+
+	fmt.Printf("checked %d | failed %d | repaired %d\n", len(gifsByHash), failed, repaired)
+	if ctx.Err() != nil {
+		return fmt.Errorf("interrupted: %w", ctx.Err())
+	}
+	return nil
+}
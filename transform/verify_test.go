@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+type stubStore struct {
+	bs  []byte
+	err error
+}
+
+func (s stubStore) Put(ctx context.Context, hash string, bs []byte) error { return nil }
+func (s stubStore) Stat(ctx context.Context, hash string) (bool, error)   { return s.bs != nil, nil }
+func (s stubStore) Get(ctx context.Context, hash string) ([]byte, error)  { return s.bs, s.err }
+
+func encodeTestGif(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewPaletted(image.Rect(0, 0, w, h), color.Palette{color.Black, color.White})
+	buf := &bytes.Buffer{}
+	if err := gif.Encode(buf, img, nil); err != nil {
+		t.Fatalf("failed to encode test gif: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func Test_checkGif(t *testing.T) {
+	bs := encodeTestGif(t, 4, 4)
+	checksum := checksumFor(bs)
+
+	t.Run("passes every check", func(t *testing.T) {
+		g := &Gif{Checksum: checksum, Width: 4, Height: 4}
+		report, got := checkGif(context.Background(), g, stubStore{bs: bs}, 0)
+		if report != nil {
+			t.Fatalf("expected no report, got %+v", report)
+		}
+		if string(got) != string(bs) {
+			t.Error("expected the fetched bytes back")
+		}
+	})
+
+	t.Run("fetch failure", func(t *testing.T) {
+		g := &Gif{Checksum: checksum, Width: 4, Height: 4}
+		report, _ := checkGif(context.Background(), g, stubStore{err: errors.New("boom")}, 0)
+		if report == nil || report.Reason != reasonFetchFailed {
+			t.Fatalf("expected reasonFetchFailed, got %+v", report)
+		}
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		g := &Gif{Checksum: "WRONGCHECKSUM", Width: 4, Height: 4}
+		report, _ := checkGif(context.Background(), g, stubStore{bs: bs}, 0)
+		if report == nil || report.Reason != reasonChecksumMismatch {
+			t.Fatalf("expected reasonChecksumMismatch, got %+v", report)
+		}
+	})
+
+	t.Run("dimension mismatch", func(t *testing.T) {
+		g := &Gif{Checksum: checksum, Width: 100, Height: 100}
+		report, _ := checkGif(context.Background(), g, stubStore{bs: bs}, 0)
+		if report == nil || report.Reason != reasonDimensionMismatch {
+			t.Fatalf("expected reasonDimensionMismatch, got %+v", report)
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		g := &Gif{Checksum: checksum, Width: 4, Height: 4}
+		report, _ := checkGif(context.Background(), g, stubStore{bs: bs}, 1)
+		if report == nil || report.Reason != reasonTooLarge {
+			t.Fatalf("expected reasonTooLarge, got %+v", report)
+		}
+	})
+
+	t.Run("decode failure", func(t *testing.T) {
+		notAGif := []byte("not a gif")
+		g := &Gif{Checksum: checksumFor(notAGif), Width: 4, Height: 4}
+		report, _ := checkGif(context.Background(), g, stubStore{bs: notAGif}, 0)
+		if report == nil || report.Reason != reasonDecodeFailed {
+			t.Fatalf("expected reasonDecodeFailed, got %+v", report)
+		}
+	})
+}